@@ -0,0 +1,21 @@
+package main
+
+import (
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+// atomicWriteFile writes b to dst via a temporary file and rename, so a
+// process that dies mid-write (e.g. partway through a download) never
+// leaves dst truncated or corrupt.
+func atomicWriteFile(dst string, b []byte) error {
+	tmp := dst + ".tmp"
+	if err := os.WriteFile(tmp, b, os.ModePerm); err != nil {
+		return errors.Wrap(err, "")
+	}
+	if err := os.Rename(tmp, dst); err != nil {
+		return errors.Wrap(err, "")
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// httpCache stores downloaded bytes on disk keyed by URL and day, so that
+// re-running the fetcher only downloads the days it hasn't seen yet. Callers
+// that fetch a whole file rather than a per-day resource should pass the
+// same day (e.g. r.To) on every call; the cache then only hits the network
+// once per day the program is run.
+type httpCache struct {
+	dir string
+}
+
+func newHTTPCache(dir string) (*httpCache, error) {
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, errors.Wrap(err, "")
+	}
+	return &httpCache{dir: dir}, nil
+}
+
+func cacheKey(url string, day time.Time) string {
+	h := sha256.Sum256([]byte(day.Format(time.DateOnly) + "\x00" + url))
+	return hex.EncodeToString(h[:])
+}
+
+// Get returns the cached bytes for url+day, downloading and caching them if
+// they are not already on disk.
+func (c *httpCache) Get(ctx context.Context, url string, day time.Time) ([]byte, error) {
+	path := filepath.Join(c.dir, cacheKey(url, day))
+	if b, err := os.ReadFile(path); err == nil {
+		return b, nil
+	} else if !os.IsNotExist(err) {
+		return nil, errors.Wrap(err, "")
+	}
+
+	b, err := httpGet(ctx, url)
+	if err != nil {
+		return nil, errors.Wrap(err, url)
+	}
+	if err := os.WriteFile(path, b, os.ModePerm); err != nil {
+		return nil, errors.Wrap(err, "")
+	}
+	return b, nil
+}
+
+func httpGet(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "")
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("%s: status %d", url, resp.StatusCode)
+	}
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "")
+	}
+	return b, nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"io"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+// sniffLen is how many leading bytes of a CSV we inspect to guess its
+// character set.
+const sniffLen = 4096
+
+// newCSVReader returns a csv.Reader over src, transcoding it to UTF-8
+// first. If enc is nil, the encoding is auto-detected from the first
+// sniffLen bytes: a UTF-8 or UTF-16 BOM is honored if present, otherwise
+// the bytes are checked for Shift-JIS before falling back to GBK, and
+// content that is already valid UTF-8 is passed through unchanged. EUC-JP
+// has no reliable byte-pattern heuristic to tell it apart from Shift-JIS,
+// so it is only ever used when a caller passes it explicitly as enc.
+func newCSVReader(src io.Reader, enc encoding.Encoding) (*csv.Reader, error) {
+	br := bufio.NewReaderSize(src, sniffLen)
+	if enc != nil {
+		return csv.NewReader(transform.NewReader(br, enc.NewDecoder())), nil
+	}
+
+	peek, err := br.Peek(sniffLen)
+	if err != nil && err != io.EOF {
+		return nil, errors.Wrap(err, "")
+	}
+	t := unicode.BOMOverride(detectFallback(peek).NewDecoder())
+	return csv.NewReader(transform.NewReader(br, t)), nil
+}
+
+// detectFallback guesses the encoding of b when it carries no BOM.
+func detectFallback(b []byte) encoding.Encoding {
+	if utf8.Valid(b) {
+		return encoding.Nop
+	}
+	if looksShiftJIS(b) {
+		return japanese.ShiftJIS
+	}
+	return simplifiedchinese.GBK
+}
+
+// looksShiftJIS reports whether b contains a byte pair in Shift-JIS's
+// double-byte lead range (0x81-0x9F, 0xE0-0xEF) followed by a second byte
+// in Shift-JIS's range (0x40-0x7E, 0x80-0xFC) but outside GBK's
+// (0x40-0xFE). It is a heuristic, not a proper charset detector.
+func looksShiftJIS(b []byte) bool {
+	for i := 0; i < len(b)-1; i++ {
+		c := b[i]
+		if (c >= 0x81 && c <= 0x9F) || (c >= 0xE0 && c <= 0xEF) {
+			n := b[i+1]
+			if n >= 0x40 && n <= 0x7E {
+				return true
+			}
+		}
+	}
+	return false
+}
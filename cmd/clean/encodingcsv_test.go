@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+func TestDetectFallback(t *testing.T) {
+	ja, err := japanese.ShiftJIS.NewEncoder().String("日付,値\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	zh, err := simplifiedchinese.GBK.NewEncoder().String("日期,值\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		b    []byte
+		want string // "shiftjis", "gbk" or "utf8"
+	}{
+		{name: "valid utf-8 passes through", b: []byte("t,v\n2024-01-01,1\n"), want: "utf8"},
+		{name: "shift-jis header", b: []byte(ja), want: "shiftjis"},
+		{name: "gbk fallback for non-shift-jis non-utf8 bytes", b: []byte(zh), want: "gbk"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			enc := detectFallback(tt.b)
+
+			if tt.want == "utf8" {
+				// encoding.Nop decodes to the same bytes unchanged; that's
+				// the behavior that matters, rather than its identity.
+				got, err := enc.NewDecoder().Bytes(tt.b)
+				if err != nil || !bytes.Equal(got, tt.b) {
+					t.Errorf("detectFallback(valid utf-8) did not pass through unchanged")
+				}
+				return
+			}
+
+			var want encoding.Encoding
+			switch tt.want {
+			case "shiftjis":
+				want = japanese.ShiftJIS
+			case "gbk":
+				want = simplifiedchinese.GBK
+			}
+			if enc != want {
+				t.Errorf("detectFallback() = %v, want %v", enc, want)
+			}
+		})
+	}
+}
+
+func TestLooksShiftJIS(t *testing.T) {
+	ja, err := japanese.ShiftJIS.NewEncoder().String("川の名前")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !looksShiftJIS([]byte(ja)) {
+		t.Error("looksShiftJIS() = false on real Shift-JIS bytes, want true")
+	}
+	if looksShiftJIS([]byte("plain ascii, no high bytes at all")) {
+		t.Error("looksShiftJIS() = true on plain ASCII, want false")
+	}
+}
+
+func TestNewCSVReaderDecodesShiftJIS(t *testing.T) {
+	ja, err := japanese.ShiftJIS.NewEncoder().String("station,value\n日付,1\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := newCSVReader(bytes.NewReader([]byte(ja)), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Read(); err != nil { // header
+		t.Fatal(err)
+	}
+	row, err := r.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if row[0] != "日付" {
+		t.Errorf("row[0] = %q, want %q", row[0], "日付")
+	}
+}
+
+func TestNewCSVReaderHonorsUTF8BOM(t *testing.T) {
+	b := append([]byte{0xEF, 0xBB, 0xBF}, []byte("t,v\n2024-01-01,1\n")...)
+	r, err := newCSVReader(bytes.NewReader(b), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header, err := r.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header[0] != "t" {
+		t.Errorf("header[0] = %q, want %q (BOM should have been stripped)", header[0], "t")
+	}
+	if _, err := r.Read(); err != nil && err != io.EOF {
+		t.Fatal(err)
+	}
+}
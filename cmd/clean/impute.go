@@ -0,0 +1,244 @@
+package main
+
+import (
+	"flag"
+	"sort"
+	"time"
+)
+
+var imputeFlag = flag.String("impute", "none", "gap-filling strategy for missing daily values: none, linear, seasonal, or kalman")
+var imputeMaxGap = flag.Int("impute-max-gap", 7, "maximum number of consecutive missing days the linear strategy will bridge")
+
+// quality is the provenance of one imputed series cell, written out to
+// data.quality.csv so that downstream model training can weight rows by
+// how much they were guessed at.
+type quality struct {
+	status   string // "observed", "interpolated", or "imputed"
+	variance float64
+}
+
+// imputeSeries fills gaps in data according to strategy, returning a dense
+// daily series (one RawDatum per calendar day between data's earliest and
+// latest observation in ref) alongside the quality of each day. Days that
+// remain unfilled (strategy "none", or a gap "linear" refuses to bridge)
+// are omitted from the returned series, matching the old drop-missing-rows
+// behavior.
+func imputeSeries(strategy string, data []RawDatum, maxGap int, ref *time.Location) ([]RawDatum, map[string]quality) {
+	days := denseDays(data, ref)
+	observed := make(map[string]float64, len(data))
+	for _, d := range data {
+		if !d.empty {
+			observed[joinDay(d.t, ref)] = d.v
+		}
+	}
+
+	out := make([]RawDatum, 0, len(days))
+	q := make(map[string]quality, len(days))
+	emit := func(day string, v float64, st string, variance float64) {
+		t, err := time.ParseInLocation(time.DateOnly, day, ref)
+		if err != nil {
+			return
+		}
+		out = append(out, RawDatum{t: t, v: v})
+		q[day] = quality{status: st, variance: variance}
+	}
+
+	switch strategy {
+	case "linear":
+		for i, day := range days {
+			if v, ok := observed[day]; ok {
+				emit(day, v, "observed", 0)
+				continue
+			}
+			v, variance, ok := linearFill(days, observed, i, maxGap)
+			if !ok {
+				continue
+			}
+			emit(day, v, "interpolated", variance)
+		}
+	case "seasonal":
+		for _, day := range days {
+			if v, ok := observed[day]; ok {
+				emit(day, v, "observed", 0)
+				continue
+			}
+			v, variance, ok := seasonalFill(day, observed)
+			if !ok {
+				continue
+			}
+			emit(day, v, "imputed", variance)
+		}
+	case "kalman":
+		xs, ps := kalmanSmooth(days, observed)
+		for i, day := range days {
+			if v, ok := observed[day]; ok {
+				emit(day, v, "observed", 0)
+				continue
+			}
+			emit(day, xs[i], "imputed", ps[i])
+		}
+	default: // "none"
+		for _, day := range days {
+			if v, ok := observed[day]; ok {
+				emit(day, v, "observed", 0)
+			}
+		}
+	}
+
+	sort.Slice(out, func(i, j int) bool { return out[i].t.Before(out[j].t) })
+	return out, q
+}
+
+// denseDays returns every calendar day, in ref, between data's earliest and
+// latest reading, inclusive.
+func denseDays(data []RawDatum, ref *time.Location) []string {
+	if len(data) == 0 {
+		return nil
+	}
+	seen := make(map[string]bool, len(data))
+	days := make([]string, 0, len(data))
+	for _, d := range data {
+		s := joinDay(d.t, ref)
+		if !seen[s] {
+			seen[s] = true
+			days = append(days, s)
+		}
+	}
+	sort.Strings(days)
+
+	start, err := time.ParseInLocation(time.DateOnly, days[0], ref)
+	if err != nil {
+		return days
+	}
+	end, err := time.ParseInLocation(time.DateOnly, days[len(days)-1], ref)
+	if err != nil {
+		return days
+	}
+
+	out := make([]string, 0, int(end.Sub(start).Hours()/24)+1)
+	for t := start; !t.After(end); t = t.AddDate(0, 0, 1) {
+		out = append(out, t.Format(time.DateOnly))
+	}
+	return out
+}
+
+// linearFill interpolates days[i] from the nearest earlier and later
+// observed days, refusing to bridge a gap wider than maxGap days. Its
+// variance grows linearly with distance from the nearer endpoint.
+func linearFill(days []string, observed map[string]float64, i, maxGap int) (v, variance float64, ok bool) {
+	prevIdx, prevV, havePrev := -1, 0.0, false
+	for j := i - 1; j >= 0 && i-j <= maxGap; j-- {
+		if val, ok := observed[days[j]]; ok {
+			prevIdx, prevV, havePrev = j, val, true
+			break
+		}
+	}
+	nextIdx, nextV, haveNext := -1, 0.0, false
+	for j := i + 1; j < len(days) && j-i <= maxGap; j++ {
+		if val, ok := observed[days[j]]; ok {
+			nextIdx, nextV, haveNext = j, val, true
+			break
+		}
+	}
+	if !havePrev || !haveNext {
+		return 0, 0, false
+	}
+	gap := nextIdx - prevIdx
+	if gap > maxGap {
+		return 0, 0, false
+	}
+	frac := float64(i-prevIdx) / float64(gap)
+	v = prevV + frac*(nextV-prevV)
+	// Variance is zero at the endpoints and peaks at the midpoint of the
+	// gap, where the interpolation is least constrained.
+	variance = frac * (1 - frac) * float64(gap)
+	return v, variance, true
+}
+
+// seasonalFill estimates day's missing value as the mean of every other
+// year's observation on the same month and day, with the sample variance of
+// those observations. Comparing month/day rather than YearDay matters
+// because a leap year's Feb 29 shifts every later YearDay by one relative to
+// a non-leap year, which would otherwise misalign, say, every June 15 after
+// a leap year with every June 16 in a non-leap one.
+func seasonalFill(day string, observed map[string]float64) (mean, variance float64, ok bool) {
+	t, err := time.Parse(time.DateOnly, day)
+	if err != nil {
+		return 0, 0, false
+	}
+	targetMonth, targetDay := t.Month(), t.Day()
+
+	var vals []float64
+	for d, v := range observed {
+		dt, err := time.Parse(time.DateOnly, d)
+		if err != nil {
+			continue
+		}
+		if dt.Month() == targetMonth && dt.Day() == targetDay {
+			vals = append(vals, v)
+		}
+	}
+	if len(vals) == 0 {
+		return 0, 0, false
+	}
+
+	var sum float64
+	for _, v := range vals {
+		sum += v
+	}
+	mean = sum / float64(len(vals))
+	if len(vals) < 2 {
+		return mean, 0, true
+	}
+	var sq float64
+	for _, v := range vals {
+		sq += (v - mean) * (v - mean)
+	}
+	variance = sq / float64(len(vals)-1)
+	return mean, variance, true
+}
+
+// kalmanSmooth runs a forward Kalman filter followed by a Rauch-Tung-
+// Striebel backward pass over days, treating the station as a random walk
+// x_{t+1} = x_t + w (process noise Q) observed as z_t = x_t + v
+// (observation noise R) on days with data. It returns the smoothed value
+// and its variance for every day, observed or not.
+func kalmanSmooth(days []string, observed map[string]float64) (xs, ps []float64) {
+	const q = 0.25 // process noise
+	const r = 1.0  // observation noise
+	n := len(days)
+	xf := make([]float64, n)
+	pf := make([]float64, n)
+
+	x, p := 0.0, 1e6 // vague prior: no information about day zero
+	if v, ok := observed[days[0]]; ok {
+		x, p = v, r
+	}
+	xf[0], pf[0] = x, p
+	for i := 1; i < n; i++ {
+		xPred := xf[i-1]
+		pPred := pf[i-1] + q
+		if v, ok := observed[days[i]]; ok {
+			k := pPred / (pPred + r)
+			xf[i] = xPred + k*(v-xPred)
+			pf[i] = (1 - k) * pPred
+		} else {
+			xf[i] = xPred
+			pf[i] = pPred
+		}
+	}
+
+	xs = make([]float64, n)
+	ps = make([]float64, n)
+	xs[n-1], ps[n-1] = xf[n-1], pf[n-1]
+	for i := n - 2; i >= 0; i-- {
+		pPred := pf[i] + q
+		c := pf[i] / pPred
+		xs[i] = xf[i] + c*(xs[i+1]-xf[i])
+		ps[i] = pf[i] + c*c*(ps[i+1]-pPred)
+		if ps[i] < 0 { // guard against floating-point underflow
+			ps[i] = 0
+		}
+	}
+	return xs, ps
+}
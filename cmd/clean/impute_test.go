@@ -0,0 +1,119 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func day(s string) time.Time {
+	t, err := time.ParseInLocation(time.DateOnly, s, tzUTC)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestImputeSeriesNoneDropsGaps(t *testing.T) {
+	data := []RawDatum{
+		{t: day("2024-01-01"), v: 1},
+		{t: day("2024-01-02"), empty: true},
+		{t: day("2024-01-03"), v: 3},
+	}
+	out, q := imputeSeries("none", data, 7, tzUTC)
+	if len(out) != 2 {
+		t.Fatalf("len(out) = %d, want 2", len(out))
+	}
+	if q["2024-01-01"].status != "observed" {
+		t.Errorf("2024-01-01 status = %q, want observed", q["2024-01-01"].status)
+	}
+	if _, ok := q["2024-01-02"]; ok {
+		t.Errorf("2024-01-02 should have been dropped, not imputed")
+	}
+}
+
+func TestImputeSeriesLinearFillsWithinMaxGap(t *testing.T) {
+	data := []RawDatum{
+		{t: day("2024-01-01"), v: 0},
+		{t: day("2024-01-02"), empty: true},
+		{t: day("2024-01-03"), empty: true},
+		{t: day("2024-01-04"), v: 9},
+	}
+	out, q := imputeSeries("linear", data, 7, tzUTC)
+	if len(out) != 4 {
+		t.Fatalf("len(out) = %d, want 4 (gap of 2 is within maxGap 7)", len(out))
+	}
+
+	byDay := make(map[string]RawDatum, len(out))
+	for _, d := range out {
+		byDay[joinDay(d.t, tzUTC)] = d
+	}
+	if got := byDay["2024-01-02"].v; math.Abs(got-3) > 1e-9 {
+		t.Errorf("2024-01-02 = %v, want 3 (1/3 of the way from 0 to 9)", got)
+	}
+	if got := byDay["2024-01-03"].v; math.Abs(got-6) > 1e-9 {
+		t.Errorf("2024-01-03 = %v, want 6 (2/3 of the way from 0 to 9)", got)
+	}
+	if q["2024-01-02"].status != "interpolated" {
+		t.Errorf("2024-01-02 status = %q, want interpolated", q["2024-01-02"].status)
+	}
+	if q["2024-01-02"].variance <= 0 {
+		t.Errorf("2024-01-02 variance = %v, want > 0 (midpoint of the gap is least constrained)", q["2024-01-02"].variance)
+	}
+}
+
+func TestImputeSeriesLinearRefusesGapBiggerThanMaxGap(t *testing.T) {
+	data := []RawDatum{
+		{t: day("2024-01-01"), v: 0},
+		{t: day("2024-01-02"), empty: true},
+		{t: day("2024-01-03"), empty: true},
+		{t: day("2024-01-04"), v: 9},
+	}
+	out, _ := imputeSeries("linear", data, 1, tzUTC)
+	if len(out) != 2 {
+		t.Fatalf("len(out) = %d, want 2 (both gap days should be refused at maxGap 1)", len(out))
+	}
+}
+
+func TestSeasonalFillUsesSameDayOfYearAcrossYears(t *testing.T) {
+	observed := map[string]float64{
+		"2021-06-15": 10,
+		"2022-06-15": 20,
+		"2023-06-15": 30,
+	}
+	mean, variance, ok := seasonalFill("2024-06-15", observed)
+	if !ok {
+		t.Fatal("seasonalFill returned ok=false, want true")
+	}
+	if math.Abs(mean-20) > 1e-9 {
+		t.Errorf("mean = %v, want 20", mean)
+	}
+	if variance <= 0 {
+		t.Errorf("variance = %v, want > 0", variance)
+	}
+}
+
+func TestSeasonalFillNoDataReturnsNotOK(t *testing.T) {
+	_, _, ok := seasonalFill("2024-06-15", map[string]float64{"2021-01-01": 1})
+	if ok {
+		t.Error("seasonalFill returned ok=true with no matching day-of-year, want false")
+	}
+}
+
+func TestKalmanSmoothInterpolatesBetweenObservations(t *testing.T) {
+	days := []string{"2024-01-01", "2024-01-02", "2024-01-03"}
+	observed := map[string]float64{
+		"2024-01-01": 0,
+		"2024-01-03": 10,
+	}
+	xs, ps := kalmanSmooth(days, observed)
+	if len(xs) != 3 || len(ps) != 3 {
+		t.Fatalf("len(xs)=%d len(ps)=%d, want 3 each", len(xs), len(ps))
+	}
+	if xs[1] <= 0 || xs[1] >= 10 {
+		t.Errorf("middle smoothed value = %v, want strictly between the two observations", xs[1])
+	}
+	if ps[1] <= 0 {
+		t.Errorf("middle variance = %v, want > 0", ps[1])
+	}
+}
@@ -2,214 +2,30 @@ package main
 
 import (
 	"bytes"
-	"cmp"
+	"context"
 	"encoding/csv"
 	"flag"
-	"fmt"
-	"io"
 	"log"
 	"os"
-	"slices"
 	"strconv"
-	"strings"
 	"time"
 
 	"github.com/pkg/errors"
 )
 
+var offline = flag.Bool("offline", false, "read each station from its local data/ file instead of fetching it over HTTP")
+
+// RawDatum is one station's reading for one day. t is the original
+// instant as reported by the source, in the source's own timezone
+// (t.Location()); stations are joined by converting t to a common
+// reference zone rather than comparing date strings directly, see
+// joinDay.
 type RawDatum struct {
 	t     time.Time
 	empty bool
 	v     float64
 }
 
-// https://nsidc.org/arcticseaicenews/sea-ice-tools/
-func readOkhotsk(fpath string) ([]RawDatum, error) {
-	f, err := os.Open(fpath)
-	if err != nil {
-		return nil, errors.Wrap(err, "")
-	}
-	defer f.Close()
-	r := csv.NewReader(f)
-
-	// Header.
-	if _, err := r.Read(); err != nil {
-		return nil, errors.Wrap(err, "")
-	}
-
-	data := make([]RawDatum, 0)
-	var i int = 1
-	for {
-		i++
-		row, err := r.Read()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			return nil, errors.Wrap(err, fmt.Sprintf("%d", i))
-		}
-
-		for col := 2; col < len(row); col++ {
-			// Nonexistent February 29th.
-			if row[0] == "2" && row[1] == "29" && row[col] == "" {
-				continue
-			}
-
-			year := 1978 + col - 2
-			tStr := fmt.Sprintf("%d-%s-%s", year, row[0], row[1])
-			t, err := time.Parse("2006-1-2", tStr)
-			if err != nil {
-				return nil, errors.Wrap(err, fmt.Sprintf("%d %d", i, col))
-			}
-			d := RawDatum{t: t, empty: true}
-
-			if row[col] != "" {
-				d.v, err = strconv.ParseFloat(row[col], 64)
-				if err != nil {
-					return nil, errors.Wrap(err, fmt.Sprintf("%d %d", i, col))
-				}
-				d.empty = false
-			}
-
-			data = append(data, d)
-		}
-	}
-
-	slices.SortFunc(data, func(a, b RawDatum) int { return cmp.Compare(a.t.Unix(), b.t.Unix()) })
-	return data, nil
-}
-
-// https://github.com/Raingel/historical_weather
-func readTaiwan(fpath string) ([]RawDatum, error) {
-	f, err := os.Open(fpath)
-	if err != nil {
-		return nil, errors.Wrap(err, "")
-	}
-	defer f.Close()
-	r := csv.NewReader(f)
-
-	// Header.
-	if _, err := r.Read(); err != nil {
-		return nil, errors.Wrap(err, "")
-	}
-
-	data := make([]RawDatum, 0)
-	var i int = 1
-	for {
-		i++
-		row, err := r.Read()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			return nil, errors.Wrap(err, fmt.Sprintf("%d", i))
-		}
-
-		t, err := time.Parse("2006-01-02", row[0])
-		if err != nil {
-			return nil, errors.Wrap(err, fmt.Sprintf("%d", i))
-		}
-		v, err := strconv.ParseFloat(row[7], 64)
-		if err != nil {
-			return nil, errors.Wrap(err, fmt.Sprintf("%d", i))
-		}
-
-		d := RawDatum{t: t, v: v}
-		data = append(data, d)
-	}
-
-	return data, nil
-}
-
-// https://www.data.jma.go.jp/gmd/risk/obsdl/index.php
-func readJapan(fname string) ([]RawDatum, error) {
-	f, err := os.Open(fname)
-	if err != nil {
-		return nil, errors.Wrap(err, "")
-	}
-	defer f.Close()
-	r := csv.NewReader(f)
-
-	// Header.
-	if _, err := r.Read(); err != nil {
-		return nil, errors.Wrap(err, "")
-	}
-
-	data := make([]RawDatum, 0)
-	var i int = 1
-	for {
-		i++
-		row, err := r.Read()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			return nil, errors.Wrap(err, fmt.Sprintf("%d", i))
-		}
-
-		t, err := time.Parse("1/2/2006", row[0])
-		if err != nil {
-			return nil, errors.Wrap(err, fmt.Sprintf("%d", i))
-		}
-		d := RawDatum{t: t, empty: true}
-
-		if row[1] != "" {
-			d.v, err = strconv.ParseFloat(row[1], 64)
-			if err != nil {
-				return nil, errors.Wrap(err, fmt.Sprintf("%d", i))
-			}
-			d.empty = false
-		}
-
-		data = append(data, d)
-	}
-
-	return data, nil
-}
-
-// https://www.ncei.noaa.gov/access/search/data-search/global-summary-of-the-day
-func readGSOD(fname string) ([]RawDatum, error) {
-	f, err := os.Open(fname)
-	if err != nil {
-		return nil, errors.Wrap(err, "")
-	}
-	defer f.Close()
-	r := csv.NewReader(f)
-
-	// Header.
-	if _, err := r.Read(); err != nil {
-		return nil, errors.Wrap(err, "")
-	}
-
-	data := make([]RawDatum, 0)
-	var i int = 1
-	for {
-		i++
-		row, err := r.Read()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			return nil, errors.Wrap(err, fmt.Sprintf("%d", i))
-		}
-
-		t, err := time.Parse("2006-01-02", row[1])
-		if err != nil {
-			return nil, errors.Wrap(err, fmt.Sprintf("%d", i))
-		}
-		v, err := strconv.ParseFloat(strings.TrimSpace(row[2]), 64)
-		if err != nil {
-			return nil, errors.Wrap(err, fmt.Sprintf("%d", i))
-		}
-		v = (v - 32) * 5 / 9 // fahrenheit to celsius
-		d := RawDatum{t: t, v: v}
-
-		data = append(data, d)
-	}
-
-	return data, nil
-}
-
 type Datum struct {
 	t        time.Time
 	danshui  float64
@@ -228,7 +44,7 @@ func write(dst string, data []Datum) error {
 	}
 
 	for _, d := range data {
-		row[0] = d.t.Format(time.DateOnly)
+		row[0] = d.t.Format(time.RFC3339)
 		row[1] = strconv.FormatFloat(d.danshui, 'f', -1, 64)
 		row[2] = strconv.FormatFloat(d.okhotsk, 'f', -1, 64)
 		row[3] = strconv.FormatFloat(d.katsuura, 'f', -1, 64)
@@ -244,66 +60,138 @@ func write(dst string, data []Datum) error {
 		return errors.Wrap(err, "")
 	}
 
-	if err := os.WriteFile(dst, b.Bytes(), os.ModePerm); err != nil {
+	if err := atomicWriteFile(dst, b.Bytes()); err != nil {
 		return errors.Wrap(err, "")
 	}
 	return nil
 }
 
+// registerSources wires up the Source for every station this binary knows
+// about. Adding a new station means adding a Register call here, not
+// touching mainWithErr.
+func registerSources(cache *httpCache, offline bool) {
+	Register(NewOkhotskSource(cache, offline))
+	Register(NewTaiwanSource(cache, offline))
+	Register(NewJapanSource(cache, offline, "katsuura", "44", "1141", "data/katsuura.csv"))
+	Register(NewJapanSource(cache, offline, "nemuro", "35", "1834", "data/nemuro.csv"))
+	Register(NewGSODSource(cache, offline))
+}
+
 func main() {
-	flag.Parse()
 	log.SetFlags(log.LstdFlags | log.Lmicroseconds | log.Llongfile)
+
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "report":
+			if err := reportMain(os.Args[2:]); err != nil {
+				log.Fatalf("%+v", err)
+			}
+			return
+		case "serve":
+			if err := serveMain(os.Args[2:]); err != nil {
+				log.Fatalf("%+v", err)
+			}
+			return
+		}
+	}
+
+	flag.Parse()
 	if err := mainWithErr(); err != nil {
 		log.Fatalf("%+v", err)
 	}
 }
 
 func mainWithErr() error {
-	okhotsk, err := readOkhotsk("data/okhotsk.csv")
+	ctx := context.Background()
+	cache, err := newHTTPCache(".cache")
 	if err != nil {
 		return errors.Wrap(err, "")
 	}
-	danshui, err := readTaiwan("data/danshui.csv")
+	registerSources(cache, *offline)
+	refLoc, err := time.LoadLocation(*referenceTZ)
 	if err != nil {
-		return errors.Wrap(err, "")
+		return errors.Wrap(err, *referenceTZ)
 	}
-	katsuura, err := readJapan("data/katsuura.csv")
+
+	joined, qualities, err := buildJoined(ctx, refLoc, Range{To: time.Now()}, nil)
 	if err != nil {
 		return errors.Wrap(err, "")
 	}
-	nemuro, err := readJapan("data/nemuro.csv")
-	if err != nil {
+
+	if err := write("data.csv", joined); err != nil {
 		return errors.Wrap(err, "")
 	}
-	yelizovo, err := readGSOD("data/yelizovo.csv")
-	if err != nil {
+	if err := writeQuality("data.quality.csv", qualities); err != nil {
 		return errors.Wrap(err, "")
 	}
+	return nil
+}
+
+// buildJoined fetches every registered Source over r, fills gaps per
+// *imputeFlag, and joins the results into data.csv and data.quality.csv
+// rows the way mainWithErr always has. serveMain calls this with a narrow
+// r on each tick instead of refetching the whole history; history supplies
+// each source's already-joined prior readings (keyed by Source.Name()) so
+// that imputeSeries has the trailing/surrounding context linear, seasonal
+// and Kalman filling all need even when r itself only spans a day or two.
+// history may be nil, in which case imputation sees only r's own data, as
+// before.
+func buildJoined(ctx context.Context, refLoc *time.Location, r Range, history map[string][]RawDatum) ([]Datum, []QualityRow, error) {
+	raw := make(map[string][]RawDatum, len(sources))
+	for _, src := range Sources() {
+		d, err := src.Fetch(ctx, r)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, src.Name())
+		}
+		if h := history[src.Name()]; len(h) > 0 {
+			d = append(append([]RawDatum{}, h...), d...)
+		}
+		raw[src.Name()] = d
+	}
+
+	okhotsk, okhotskQ := imputeSeries(*imputeFlag, raw["okhotsk"], *imputeMaxGap, refLoc)
+	danshui, danshuiQ := imputeSeries(*imputeFlag, raw["danshui"], *imputeMaxGap, refLoc)
+	katsuura, katsuuraQ := imputeSeries(*imputeFlag, raw["katsuura"], *imputeMaxGap, refLoc)
+	nemuro, nemuroQ := imputeSeries(*imputeFlag, raw["nemuro"], *imputeMaxGap, refLoc)
+	yelizovo, yelizovoQ := imputeSeries(*imputeFlag, raw["yelizovo"], *imputeMaxGap, refLoc)
 
 	okhotskM := make(map[string]RawDatum, len(okhotsk))
 	for _, d := range okhotsk {
-		s := d.t.Format(time.DateOnly)
+		s := joinDay(d.t, refLoc)
 		okhotskM[s] = d
 	}
 	danshuiM := make(map[string]struct{}, len(danshui))
 	katsuuraM := make(map[string]RawDatum, len(katsuura))
 	for _, d := range katsuura {
-		s := d.t.Format(time.DateOnly)
+		s := joinDay(d.t, refLoc)
 		katsuuraM[s] = d
 	}
 	nemuroM := make(map[string]RawDatum, len(nemuro))
 	for _, d := range nemuro {
-		s := d.t.Format(time.DateOnly)
+		s := joinDay(d.t, refLoc)
 		nemuroM[s] = d
 	}
 	yelizovoM := make(map[string]RawDatum, len(yelizovo))
 	for _, d := range yelizovo {
-		s := d.t.Format(time.DateOnly)
+		s := joinDay(d.t, refLoc)
 		yelizovoM[s] = d
 	}
+	// fromDay excludes rows that only exist to give imputeSeries history
+	// context (see buildJoined's history param): they were already joined
+	// and written on a prior tick, so re-emitting them here would just be
+	// wasted work for mergeDatumByDay to dedupe back out.
+	var fromDay string
+	if !r.From.IsZero() {
+		fromDay = joinDay(r.From, refLoc)
+	}
+
 	joined := make([]Datum, 0, len(danshui))
+	qualities := make([]QualityRow, 0, len(danshui))
 	for _, d := range danshui {
-		s := d.t.Format(time.DateOnly)
+		s := joinDay(d.t, refLoc)
+		if fromDay != "" && s < fromDay {
+			continue
+		}
 		// Ignore duplicate rows in danshui.
 		if _, ok := danshuiM[s]; ok {
 			continue
@@ -336,11 +224,14 @@ func mainWithErr() error {
 			continue
 		}
 
-		joined = append(joined, Datum{t: d.t, danshui: d.v, okhotsk: od.v, katsuura: kd.v, nemuro: nd.v, yelizovo: yd.v})
+		joined = append(joined, Datum{t: d.t.In(refLoc), danshui: d.v, okhotsk: od.v, katsuura: kd.v, nemuro: nd.v, yelizovo: yd.v})
+		qualities = append(qualities, QualityRow{t: d.t.In(refLoc),
+			danshui:  danshuiQ[s],
+			okhotsk:  okhotskQ[s],
+			katsuura: katsuuraQ[s],
+			nemuro:   nemuroQ[s],
+			yelizovo: yelizovoQ[s]})
 	}
 
-	if err := write("data.csv", joined); err != nil {
-		return errors.Wrap(err, "")
-	}
-	return nil
+	return joined, qualities, nil
 }
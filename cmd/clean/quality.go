@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// QualityRow records, for one joined day, how each station's value was
+// obtained: "observed" straight from the source, or "interpolated" /
+// "imputed" by imputeSeries, plus the estimated variance of any filled
+// value so downstream model training can weight rows accordingly.
+type QualityRow struct {
+	t        time.Time
+	danshui  quality
+	okhotsk  quality
+	katsuura quality
+	nemuro   quality
+	yelizovo quality
+}
+
+func writeQuality(dst string, rows []QualityRow) error {
+	b := bytes.NewBuffer(nil)
+	w := csv.NewWriter(b)
+	header := []string{"t",
+		"danshui_status", "danshui_variance",
+		"okhotsk_status", "okhotsk_variance",
+		"katsuura_status", "katsuura_variance",
+		"nemuro_status", "nemuro_variance",
+		"yelizovo_status", "yelizovo_variance"}
+	if err := w.Write(header); err != nil {
+		return errors.Wrap(err, "")
+	}
+
+	row := make([]string, len(header))
+	for _, r := range rows {
+		row[0] = r.t.Format(time.RFC3339)
+		for i, q := range []quality{r.danshui, r.okhotsk, r.katsuura, r.nemuro, r.yelizovo} {
+			row[1+2*i] = q.status
+			row[2+2*i] = strconv.FormatFloat(q.variance, 'f', -1, 64)
+		}
+		if err := w.Write(row); err != nil {
+			return errors.Wrap(err, "")
+		}
+	}
+
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return errors.Wrap(err, "")
+	}
+
+	if err := atomicWriteFile(dst, b.Bytes()); err != nil {
+		return errors.Wrap(err, "")
+	}
+	return nil
+}
+
+// readQuality is writeQuality's inverse, used by serveMain to merge a
+// freshly fetched window into the QualityRow rows already on disk.
+func readQuality(fpath string) ([]QualityRow, error) {
+	f, err := os.Open(fpath)
+	if err != nil {
+		return nil, errors.Wrap(err, "")
+	}
+	defer f.Close()
+	r := csv.NewReader(f)
+
+	if _, err := r.Read(); err != nil { // Header.
+		return nil, errors.Wrap(err, "")
+	}
+
+	rows := make([]QualityRow, 0)
+	for {
+		row, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "")
+		}
+
+		t, err := time.Parse(time.RFC3339, row[0])
+		if err != nil {
+			return nil, errors.Wrap(err, "")
+		}
+		qs := make([]quality, 5)
+		for i := range qs {
+			v, err := strconv.ParseFloat(row[2+2*i], 64)
+			if err != nil {
+				return nil, errors.Wrap(err, "")
+			}
+			qs[i] = quality{status: row[1+2*i], variance: v}
+		}
+		rows = append(rows, QualityRow{t: t,
+			danshui:  qs[0],
+			okhotsk:  qs[1],
+			katsuura: qs[2],
+			nemuro:   qs[3],
+			yelizovo: qs[4]})
+	}
+
+	return rows, nil
+}
@@ -0,0 +1,484 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"html/template"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/palette"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/vg"
+)
+
+// reportStations is the canonical plotting order for the five joined
+// columns in data.csv.
+var reportStations = []string{"danshui", "okhotsk", "katsuura", "nemuro", "yelizovo"}
+
+// reportMain implements the "climate report" subcommand: it reads the
+// joined CSV written by mainWithErr and produces diagnostic plots plus an
+// index.html summarizing them, so a fresh ingest can be sanity-checked
+// without reaching for a notebook.
+func reportMain(args []string) error {
+	fs := flag.NewFlagSet("report", flag.ExitOnError)
+	in := fs.String("in", "data.csv", "joined data CSV produced by the default climate run")
+	qualityIn := fs.String("quality", "data.quality.csv", "joined quality CSV produced by the default climate run, used to compute each station's missing-data percentage")
+	out := fs.String("out", "reports", "directory to write the generated plots and index.html into")
+	if err := fs.Parse(args); err != nil {
+		return errors.Wrap(err, "")
+	}
+
+	rows, err := readJoined(*in)
+	if err != nil {
+		return errors.Wrap(err, "")
+	}
+	quality, err := readQuality(*qualityIn)
+	if err != nil && !os.IsNotExist(errors.Cause(err)) {
+		return errors.Wrap(err, "")
+	}
+	refLoc, err := time.LoadLocation(*referenceTZ)
+	if err != nil {
+		return errors.Wrap(err, *referenceTZ)
+	}
+	qualityByDay := make(map[string]QualityRow, len(quality))
+	for _, q := range quality {
+		qualityByDay[joinDay(q.t, refLoc)] = q
+	}
+	if err := os.MkdirAll(*out, os.ModePerm); err != nil {
+		return errors.Wrap(err, "")
+	}
+
+	summaries := make([]stationSummary, 0, len(reportStations))
+	for _, name := range reportStations {
+		if err := plotTimeSeries(rows, name, filepath.Join(*out, name+".png")); err != nil {
+			return errors.Wrap(err, name)
+		}
+		summaries = append(summaries, summarize(rows, qualityByDay, name, refLoc))
+	}
+
+	if err := plotScatterMatrix(rows, filepath.Join(*out, "scatter.png")); err != nil {
+		return errors.Wrap(err, "")
+	}
+	if err := plotSeasonalDecomposition(rows, "danshui", filepath.Join(*out, "seasonal_danshui.png")); err != nil {
+		return errors.Wrap(err, "")
+	}
+	if err := plotCorrelationHeatmap(rows, filepath.Join(*out, "correlation.png")); err != nil {
+		return errors.Wrap(err, "")
+	}
+
+	if err := writeReportIndex(*out, summaries); err != nil {
+		return errors.Wrap(err, "")
+	}
+	return nil
+}
+
+// stationValue extracts the named column from a joined Datum.
+func stationValue(d Datum, name string) float64 {
+	switch name {
+	case "danshui":
+		return d.danshui
+	case "okhotsk":
+		return d.okhotsk
+	case "katsuura":
+		return d.katsuura
+	case "nemuro":
+		return d.nemuro
+	case "yelizovo":
+		return d.yelizovo
+	default:
+		return 0
+	}
+}
+
+// stationQuality extracts the named column's quality from a QualityRow, the
+// quality.go counterpart to stationValue.
+func stationQuality(q QualityRow, name string) quality {
+	switch name {
+	case "danshui":
+		return q.danshui
+	case "okhotsk":
+		return q.okhotsk
+	case "katsuura":
+		return q.katsuura
+	case "nemuro":
+		return q.nemuro
+	case "yelizovo":
+		return q.yelizovo
+	default:
+		return quality{}
+	}
+}
+
+func readJoined(fpath string) ([]Datum, error) {
+	f, err := os.Open(fpath)
+	if err != nil {
+		return nil, errors.Wrap(err, "")
+	}
+	defer f.Close()
+	r := csv.NewReader(f)
+
+	if _, err := r.Read(); err != nil { // Header.
+		return nil, errors.Wrap(err, "")
+	}
+
+	data := make([]Datum, 0)
+	for {
+		row, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "")
+		}
+
+		t, err := time.Parse(time.RFC3339, row[0])
+		if err != nil {
+			return nil, errors.Wrap(err, row[0])
+		}
+		vals := make([]float64, 5)
+		for i := range vals {
+			vals[i], err = strconv.ParseFloat(row[1+i], 64)
+			if err != nil {
+				return nil, errors.Wrap(err, row[1+i])
+			}
+		}
+		data = append(data, Datum{t: t, danshui: vals[0], okhotsk: vals[1], katsuura: vals[2], nemuro: vals[3], yelizovo: vals[4]})
+	}
+	return data, nil
+}
+
+// plotTimeSeries draws name's values against time, shading any gap of more
+// than one day between consecutive rows so missing stretches are visible
+// at a glance.
+func plotTimeSeries(rows []Datum, name, dst string) error {
+	p := plot.New()
+	p.Title.Text = name
+	p.X.Label.Text = "date"
+	p.Y.Label.Text = "°C"
+	p.X.Tick.Marker = plot.TimeTicks{Format: "2006-01"}
+
+	pts := make(plotter.XYs, len(rows))
+	for i, d := range rows {
+		pts[i].X = float64(d.t.Unix())
+		pts[i].Y = stationValue(d, name)
+	}
+	line, err := plotter.NewLine(pts)
+	if err != nil {
+		return errors.Wrap(err, "")
+	}
+	p.Add(line)
+
+	for i := 1; i < len(rows); i++ {
+		gapDays := rows[i].t.Sub(rows[i-1].t).Hours() / 24
+		if gapDays <= 1.5 {
+			continue
+		}
+		shade, err := plotter.NewPolygon(plotter.XYs{
+			{X: float64(rows[i-1].t.Unix()), Y: p.Y.Min},
+			{X: float64(rows[i].t.Unix()), Y: p.Y.Min},
+			{X: float64(rows[i].t.Unix()), Y: p.Y.Max},
+			{X: float64(rows[i-1].t.Unix()), Y: p.Y.Max},
+		})
+		if err != nil {
+			return errors.Wrap(err, "")
+		}
+		shade.Color = palette.Heat(12, 0.3).Colors()[0]
+		shade.LineStyle.Width = 0
+		p.Add(shade)
+	}
+
+	if err := p.Save(8*vg.Inch, 4*vg.Inch, dst); err != nil {
+		return errors.Wrap(err, "")
+	}
+	return nil
+}
+
+// plotScatterMatrix draws Danshui against each of the four northern
+// stations, one scatter plot per pane.
+func plotScatterMatrix(rows []Datum, dst string) error {
+	p := plot.New()
+	p.Title.Text = "danshui vs. northern stations"
+	p.X.Label.Text = "danshui °C"
+	p.Y.Label.Text = "°C"
+
+	northern := []string{"okhotsk", "katsuura", "nemuro", "yelizovo"}
+	for i, name := range northern {
+		pts := make(plotter.XYs, len(rows))
+		for j, d := range rows {
+			pts[j].X = d.danshui
+			pts[j].Y = stationValue(d, name)
+		}
+		s, err := plotter.NewScatter(pts)
+		if err != nil {
+			return errors.Wrap(err, name)
+		}
+		s.Color = palette.Heat(len(northern)*3, 1).Colors()[i*3]
+		p.Add(s)
+		p.Legend.Add(name, s)
+	}
+
+	if err := p.Save(8*vg.Inch, 6*vg.Inch, dst); err != nil {
+		return errors.Wrap(err, "")
+	}
+	return nil
+}
+
+// plotSeasonalDecomposition splits name's series into trend (a 365-day
+// centered moving average), seasonal (the mean residual for each
+// day-of-year once detrended), and what's left over as the residual.
+func plotSeasonalDecomposition(rows []Datum, name, dst string) error {
+	n := len(rows)
+	vals := make([]float64, n)
+	for i, d := range rows {
+		vals[i] = stationValue(d, name)
+	}
+
+	const window = 365
+	trend := make([]float64, n)
+	for i := range vals {
+		lo, hi := i-window/2, i+window/2
+		if lo < 0 {
+			lo = 0
+		}
+		if hi > n {
+			hi = n
+		}
+		var sum float64
+		for j := lo; j < hi; j++ {
+			sum += vals[j]
+		}
+		trend[i] = sum / float64(hi-lo)
+	}
+
+	seasonalSum := make(map[int]float64, 366)
+	seasonalCount := make(map[int]int, 366)
+	for i, d := range rows {
+		doy := d.t.YearDay()
+		seasonalSum[doy] += vals[i] - trend[i]
+		seasonalCount[doy]++
+	}
+	seasonal := make([]float64, n)
+	residual := make([]float64, n)
+	for i, d := range rows {
+		doy := d.t.YearDay()
+		seasonal[i] = seasonalSum[doy] / float64(seasonalCount[doy])
+		residual[i] = vals[i] - trend[i] - seasonal[i]
+	}
+
+	p := plot.New()
+	p.Title.Text = name + " seasonal decomposition"
+	p.X.Label.Text = "date"
+	p.X.Tick.Marker = plot.TimeTicks{Format: "2006-01"}
+
+	series := []struct {
+		label string
+		v     []float64
+	}{
+		{"observed", vals},
+		{"trend", trend},
+		{"seasonal", seasonal},
+		{"residual", residual},
+	}
+	for i, s := range series {
+		pts := make(plotter.XYs, n)
+		for j, d := range rows {
+			pts[j].X = float64(d.t.Unix())
+			pts[j].Y = s.v[j]
+		}
+		line, err := plotter.NewLine(pts)
+		if err != nil {
+			return errors.Wrap(err, s.label)
+		}
+		line.Color = palette.Heat(len(series)*3, 1).Colors()[i*3]
+		p.Add(line)
+		p.Legend.Add(s.label, line)
+	}
+
+	if err := p.Save(8*vg.Inch, 6*vg.Inch, dst); err != nil {
+		return errors.Wrap(err, "")
+	}
+	return nil
+}
+
+// correlationGrid is a plotter.GridXYZ over the Pearson correlation matrix
+// of reportStations.
+type correlationGrid struct {
+	m [][]float64
+}
+
+func (g correlationGrid) Dims() (c, r int)   { return len(g.m), len(g.m) }
+func (g correlationGrid) Z(c, r int) float64 { return g.m[r][c] }
+func (g correlationGrid) X(c int) float64    { return float64(c) }
+func (g correlationGrid) Y(r int) float64    { return float64(r) }
+
+// plotCorrelationHeatmap draws the pairwise Pearson correlation between
+// every station.
+func plotCorrelationHeatmap(rows []Datum, dst string) error {
+	m := make([][]float64, len(reportStations))
+	for i, a := range reportStations {
+		m[i] = make([]float64, len(reportStations))
+		for j, b := range reportStations {
+			m[i][j] = pearson(rows, a, b)
+		}
+	}
+
+	p := plot.New()
+	p.Title.Text = "cross-station correlation"
+	h := plotter.NewHeatMap(correlationGrid{m: m}, palette.Heat(12, 1))
+	p.Add(h)
+
+	if err := p.Save(6*vg.Inch, 6*vg.Inch, dst); err != nil {
+		return errors.Wrap(err, "")
+	}
+	return nil
+}
+
+func pearson(rows []Datum, a, b string) float64 {
+	n := float64(len(rows))
+	if n == 0 {
+		return 0
+	}
+	var sumA, sumB, sumAB, sumA2, sumB2 float64
+	for _, d := range rows {
+		va, vb := stationValue(d, a), stationValue(d, b)
+		sumA += va
+		sumB += vb
+		sumAB += va * vb
+		sumA2 += va * va
+		sumB2 += vb * vb
+	}
+	num := n*sumAB - sumA*sumB
+	den := (n*sumA2 - sumA*sumA) * (n*sumB2 - sumB*sumB)
+	if den <= 0 {
+		return 0
+	}
+	return num / math.Sqrt(den)
+}
+
+// stationSummary is one row of the report's summary table: overall stats
+// plus a per-year breakdown, so a reviewer can spot a single bad year
+// without having to re-read the time series plot.
+type stationSummary struct {
+	Name          string
+	Rows          int
+	MissingPct    float64
+	Min, Max, Avg float64
+	Years         []yearSummary
+}
+
+// yearSummary is stationSummary's stats narrowed to a single calendar year.
+type yearSummary struct {
+	Year          int
+	Rows          int
+	MissingPct    float64
+	Min, Max, Avg float64
+}
+
+// summarize computes stationSummary for name from rows. quality, keyed by
+// joinDay, is used to count what fraction of rows were filled in by
+// imputeSeries (status != "observed") rather than actually measured; it may
+// be nil if data.quality.csv wasn't produced, in which case MissingPct is
+// reported as 0.
+func summarize(rows []Datum, quality map[string]QualityRow, name string, refLoc *time.Location) stationSummary {
+	s := stationSummary{Name: name}
+	if len(rows) == 0 {
+		return s
+	}
+
+	byYear := make(map[int][]Datum)
+	for _, d := range rows {
+		byYear[d.t.Year()] = append(byYear[d.t.Year()], d)
+	}
+	years := make([]int, 0, len(byYear))
+	for y := range byYear {
+		years = append(years, y)
+	}
+	sort.Ints(years)
+
+	n, missingPct, min, max, avg := stationStats(rows, quality, name, refLoc)
+	s.Rows, s.MissingPct, s.Min, s.Max, s.Avg = n, missingPct, min, max, avg
+	s.Years = make([]yearSummary, 0, len(years))
+	for _, y := range years {
+		n, missingPct, min, max, avg := stationStats(byYear[y], quality, name, refLoc)
+		s.Years = append(s.Years, yearSummary{Year: y, Rows: n, MissingPct: missingPct, Min: min, Max: max, Avg: avg})
+	}
+	return s
+}
+
+// stationStats computes the row count, missing-data percentage, min, max
+// and mean of name over rows.
+func stationStats(rows []Datum, quality map[string]QualityRow, name string, refLoc *time.Location) (n int, missingPct, min, max, avg float64) {
+	n = len(rows)
+	min, max = stationValue(rows[0], name), stationValue(rows[0], name)
+	var sum float64
+	var missing int
+	for _, d := range rows {
+		v := stationValue(d, name)
+		if v < min {
+			min = v
+		}
+		if v > max {
+			max = v
+		}
+		sum += v
+		if q, ok := quality[joinDay(d.t, refLoc)]; ok && stationQuality(q, name).status != "observed" {
+			missing++
+		}
+	}
+	avg = sum / float64(n)
+	missingPct = 100 * float64(missing) / float64(n)
+	return n, missingPct, min, max, avg
+}
+
+const reportIndexTmpl = `<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>climate report</title></head>
+<body>
+<h1>climate report</h1>
+<table border="1" cellpadding="4">
+<tr><th>station</th><th>rows</th><th>missing %</th><th>min</th><th>max</th><th>mean</th></tr>
+{{range .}}<tr><td>{{.Name}}</td><td>{{.Rows}}</td><td>{{printf "%.1f" .MissingPct}}</td><td>{{printf "%.2f" .Min}}</td><td>{{printf "%.2f" .Max}}</td><td>{{printf "%.2f" .Avg}}</td></tr>
+{{end}}</table>
+<h2>per-year breakdown</h2>
+{{range .}}<h3>{{.Name}}</h3>
+<table border="1" cellpadding="4">
+<tr><th>year</th><th>rows</th><th>missing %</th><th>min</th><th>max</th><th>mean</th></tr>
+{{range .Years}}<tr><td>{{.Year}}</td><td>{{.Rows}}</td><td>{{printf "%.1f" .MissingPct}}</td><td>{{printf "%.2f" .Min}}</td><td>{{printf "%.2f" .Max}}</td><td>{{printf "%.2f" .Avg}}</td></tr>
+{{end}}</table>
+{{end}}
+<h2>time series</h2>
+{{range .}}<img src="{{.Name}}.png"><br>
+{{end}}
+<h2>danshui vs. northern stations</h2>
+<img src="scatter.png"><br>
+<h2>seasonal decomposition</h2>
+<img src="seasonal_danshui.png"><br>
+<h2>correlation</h2>
+<img src="correlation.png"><br>
+</body>
+</html>
+`
+
+func writeReportIndex(dir string, summaries []stationSummary) error {
+	tmpl, err := template.New("index").Parse(reportIndexTmpl)
+	if err != nil {
+		return errors.Wrap(err, "")
+	}
+	f, err := os.Create(filepath.Join(dir, "index.html"))
+	if err != nil {
+		return errors.Wrap(err, "")
+	}
+	defer f.Close()
+	if err := tmpl.Execute(f, summaries); err != nil {
+		return errors.Wrap(err, "")
+	}
+	return nil
+}
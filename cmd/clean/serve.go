@@ -0,0 +1,263 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/robfig/cron/v3"
+)
+
+// serveState is the on-disk record of how far the fetcher has gotten, so a
+// daily tick only asks each Source for the days it hasn't already fetched
+// instead of re-downloading a station's whole history every time.
+type serveState struct {
+	LastFetch time.Time            `json:"last_fetch"`
+	HighWater map[string]time.Time `json:"high_water"`
+}
+
+func loadServeState(fpath string) (*serveState, error) {
+	b, err := os.ReadFile(fpath)
+	if os.IsNotExist(err) {
+		return &serveState{HighWater: make(map[string]time.Time)}, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "")
+	}
+
+	var s serveState
+	if err := json.Unmarshal(b, &s); err != nil {
+		return nil, errors.Wrap(err, "")
+	}
+	if s.HighWater == nil {
+		s.HighWater = make(map[string]time.Time)
+	}
+	return &s, nil
+}
+
+func (s *serveState) save(fpath string) error {
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "")
+	}
+	if err := atomicWriteFile(fpath, b); err != nil {
+		return errors.Wrap(err, "")
+	}
+	return nil
+}
+
+// serveMain implements "climate serve", a long-running daemon that refetches
+// only new days on a cron schedule and appends them to data.csv instead of
+// rebuilding it from scratch, so a multi-year run finishes in seconds
+// instead of refetching every station's entire history each day.
+func serveMain(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	daily := fs.String("daily", "03:00", "local HH:MM at which to run the daily incremental fetch")
+	backfill := fs.String("backfill", "", "FROM..TO (YYYY-MM-DD..YYYY-MM-DD) to force a re-fetch of a historical window, e.g. after a JMA revision")
+	dataCSV := fs.String("data", "data.csv", "path of the joined data csv to append to")
+	qualityCSV := fs.String("quality", "data.quality.csv", "path of the joined quality csv to append to")
+	stateFile := fs.String("state", "data.state.json", "path of the state file recording per-source high-water dates")
+	if err := fs.Parse(args); err != nil {
+		return errors.Wrap(err, "")
+	}
+
+	ctx := context.Background()
+	cache, err := newHTTPCache(".cache")
+	if err != nil {
+		return errors.Wrap(err, "")
+	}
+	registerSources(cache, *offline)
+	refLoc, err := time.LoadLocation(*referenceTZ)
+	if err != nil {
+		return errors.Wrap(err, *referenceTZ)
+	}
+
+	st, err := loadServeState(*stateFile)
+	if err != nil {
+		return errors.Wrap(err, *stateFile)
+	}
+
+	if *backfill != "" {
+		// Relies on every Source's Fetch actually paginating over
+		// [r.From, r.To] rather than only looking at r.To; otherwise a
+		// backfill window silently degenerates into refetching just the
+		// day/year containing TO, which defeats the point of re-requesting
+		// a revised historical range (see JMA's late revisions).
+		from, to, err := parseBackfillRange(*backfill)
+		if err != nil {
+			return errors.Wrap(err, *backfill)
+		}
+		if err := tick(ctx, refLoc, st, Range{From: from, To: to}, *dataCSV, *qualityCSV, *stateFile); err != nil {
+			return errors.Wrap(err, "")
+		}
+	}
+
+	hh, mm, err := parseHHMM(*daily)
+	if err != nil {
+		return errors.Wrap(err, *daily)
+	}
+
+	c := cron.New()
+	spec := fmt.Sprintf("%d %d * * *", mm, hh)
+	_, err = c.AddFunc(spec, func() {
+		from := st.LastFetch
+		if !from.IsZero() {
+			from = from.AddDate(0, 0, 1)
+		}
+		r := Range{From: from, To: time.Now()}
+		if err := tick(ctx, refLoc, st, r, *dataCSV, *qualityCSV, *stateFile); err != nil {
+			log.Printf("%+v", err)
+		}
+	})
+	if err != nil {
+		return errors.Wrap(err, "")
+	}
+	c.Run()
+	return nil
+}
+
+// tick fetches r from every Source, then merges the result into the rows
+// already on disk by calendar day, so a backfill window overwrites stale
+// rows instead of duplicating them. It writes both csvs atomically and
+// advances st's high-water marks to r.To on success.
+func tick(ctx context.Context, refLoc *time.Location, st *serveState, r Range, dataCSV, qualityCSV, stateFile string) error {
+	existing, err := readJoined(dataCSV)
+	if err != nil && !os.IsNotExist(errors.Cause(err)) {
+		return errors.Wrap(err, "")
+	}
+
+	// r is typically just a day or two wide, far too narrow for
+	// imputeSeries's linear/seasonal/Kalman strategies to bridge a gap
+	// with. Seed each source's series with its already-joined history so
+	// gap-filling has the neighbors it needs even on a routine daily tick.
+	history := historyFromJoined(existing)
+	joined, qualities, err := buildJoined(ctx, refLoc, r, history)
+	if err != nil {
+		return errors.Wrap(err, "")
+	}
+
+	merged := mergeDatumByDay(existing, joined, refLoc)
+	if err := write(dataCSV, merged); err != nil {
+		return errors.Wrap(err, "")
+	}
+
+	existingQ, err := readQuality(qualityCSV)
+	if err != nil && !os.IsNotExist(errors.Cause(err)) {
+		return errors.Wrap(err, "")
+	}
+	mergedQ := mergeQualityByDay(existingQ, qualities, refLoc)
+	if err := writeQuality(qualityCSV, mergedQ); err != nil {
+		return errors.Wrap(err, "")
+	}
+
+	for _, src := range Sources() {
+		if cur, ok := st.HighWater[src.Name()]; !ok || r.To.After(cur) {
+			st.HighWater[src.Name()] = r.To
+		}
+	}
+	if st.LastFetch.IsZero() || r.To.After(st.LastFetch) {
+		st.LastFetch = r.To
+	}
+	if err := st.save(stateFile); err != nil {
+		return errors.Wrap(err, "")
+	}
+	return nil
+}
+
+// historyFromJoined turns already-joined rows back into a per-source
+// RawDatum series, keyed by Source.Name(), for seeding buildJoined's
+// imputeSeries calls. Every joined row has a value for every station (that
+// is what joining means), so none of the resulting RawDatum are empty.
+func historyFromJoined(rows []Datum) map[string][]RawDatum {
+	h := make(map[string][]RawDatum, len(reportStations))
+	for _, name := range reportStations {
+		series := make([]RawDatum, len(rows))
+		for i, d := range rows {
+			series[i] = RawDatum{t: d.t, v: stationValue(d, name)}
+		}
+		h[name] = series
+	}
+	return h
+}
+
+// mergeDatumByDay overlays fresh on top of old, keyed by joinDay, so a
+// re-fetched day (backfill, or a tick's range overlapping the prior one)
+// replaces rather than duplicates the existing row. The result is sorted by
+// t ascending.
+func mergeDatumByDay(old, fresh []Datum, refLoc *time.Location) []Datum {
+	m := make(map[string]Datum, len(old)+len(fresh))
+	for _, d := range old {
+		m[joinDay(d.t, refLoc)] = d
+	}
+	for _, d := range fresh {
+		m[joinDay(d.t, refLoc)] = d
+	}
+
+	merged := make([]Datum, 0, len(m))
+	for _, d := range m {
+		merged = append(merged, d)
+	}
+	sort.Slice(merged, func(i, j int) bool { return merged[i].t.Before(merged[j].t) })
+	return merged
+}
+
+// mergeQualityByDay is mergeDatumByDay's counterpart for QualityRow.
+func mergeQualityByDay(old, fresh []QualityRow, refLoc *time.Location) []QualityRow {
+	m := make(map[string]QualityRow, len(old)+len(fresh))
+	for _, q := range old {
+		m[joinDay(q.t, refLoc)] = q
+	}
+	for _, q := range fresh {
+		m[joinDay(q.t, refLoc)] = q
+	}
+
+	merged := make([]QualityRow, 0, len(m))
+	for _, q := range m {
+		merged = append(merged, q)
+	}
+	sort.Slice(merged, func(i, j int) bool { return merged[i].t.Before(merged[j].t) })
+	return merged
+}
+
+// parseHHMM parses a "HH:MM" local time of day, as accepted by --daily.
+func parseHHMM(s string) (hh, mm int, err error) {
+	parts := strings.SplitN(s, ":", 2)
+	if len(parts) != 2 {
+		return 0, 0, errors.Errorf("want HH:MM, got %q", s)
+	}
+	hh, err = strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, 0, errors.Wrap(err, s)
+	}
+	mm, err = strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, 0, errors.Wrap(err, s)
+	}
+	return hh, mm, nil
+}
+
+// parseBackfillRange parses a "FROM..TO" (YYYY-MM-DD..YYYY-MM-DD) window, as
+// accepted by --backfill.
+func parseBackfillRange(s string) (from, to time.Time, err error) {
+	parts := strings.SplitN(s, "..", 2)
+	if len(parts) != 2 {
+		return time.Time{}, time.Time{}, errors.Errorf("want FROM..TO, got %q", s)
+	}
+	from, err = time.Parse("2006-01-02", parts[0])
+	if err != nil {
+		return time.Time{}, time.Time{}, errors.Wrap(err, s)
+	}
+	to, err = time.Parse("2006-01-02", parts[1])
+	if err != nil {
+		return time.Time{}, time.Time{}, errors.Wrap(err, s)
+	}
+	return from, to, nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMergeDatumByDayOverwritesOnOverlap(t *testing.T) {
+	old := []Datum{
+		{t: day("2024-01-01"), danshui: 1},
+		{t: day("2024-01-02"), danshui: 2},
+	}
+	fresh := []Datum{
+		{t: day("2024-01-02"), danshui: 20}, // revised
+		{t: day("2024-01-03"), danshui: 3},
+	}
+	got := mergeDatumByDay(old, fresh, tzUTC)
+	if len(got) != 3 {
+		t.Fatalf("len(got) = %d, want 3", len(got))
+	}
+	for i := 1; i < len(got); i++ {
+		if !got[i].t.After(got[i-1].t) {
+			t.Fatalf("merged rows not sorted ascending at index %d: %v then %v", i, got[i-1].t, got[i].t)
+		}
+	}
+	if got[1].danshui != 20 {
+		t.Errorf("2024-01-02 danshui = %v, want 20 (fresh should overwrite old)", got[1].danshui)
+	}
+}
+
+func TestMergeQualityByDayOverwritesOnOverlap(t *testing.T) {
+	old := []QualityRow{
+		{t: day("2024-01-01"), danshui: quality{status: "observed"}},
+		{t: day("2024-01-02"), danshui: quality{status: "imputed", variance: 5}},
+	}
+	fresh := []QualityRow{
+		{t: day("2024-01-02"), danshui: quality{status: "observed"}}, // now actually observed
+	}
+	got := mergeQualityByDay(old, fresh, tzUTC)
+	if len(got) != 2 {
+		t.Fatalf("len(got) = %d, want 2", len(got))
+	}
+	if got[1].danshui.status != "observed" {
+		t.Errorf("2024-01-02 status = %q, want observed (fresh should overwrite old)", got[1].danshui.status)
+	}
+}
+
+func TestHistoryFromJoinedRoundTripsEveryStation(t *testing.T) {
+	rows := []Datum{
+		{t: day("2024-01-01"), danshui: 1, okhotsk: 2, katsuura: 3, nemuro: 4, yelizovo: 5},
+	}
+	h := historyFromJoined(rows)
+	for _, name := range reportStations {
+		series, ok := h[name]
+		if !ok || len(series) != 1 {
+			t.Fatalf("history[%q] = %v, want one RawDatum", name, series)
+		}
+		if series[0].empty {
+			t.Errorf("history[%q][0].empty = true, want false (joined rows are never missing)", name)
+		}
+		if got, want := series[0].v, stationValue(rows[0], name); got != want {
+			t.Errorf("history[%q][0].v = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestParseHHMM(t *testing.T) {
+	hh, mm, err := parseHHMM("03:05")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hh != 3 || mm != 5 {
+		t.Errorf("parseHHMM(\"03:05\") = %d, %d, want 3, 5", hh, mm)
+	}
+
+	if _, _, err := parseHHMM("not-a-time"); err == nil {
+		t.Error("parseHHMM(\"not-a-time\") returned nil error, want non-nil")
+	}
+}
+
+func TestParseBackfillRange(t *testing.T) {
+	from, to, err := parseBackfillRange("2020-01-01..2020-12-31")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !from.Equal(time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("from = %v, want 2020-01-01", from)
+	}
+	if !to.Equal(time.Date(2020, time.December, 31, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("to = %v, want 2020-12-31", to)
+	}
+
+	if _, _, err := parseBackfillRange("garbage"); err == nil {
+		t.Error("parseBackfillRange(\"garbage\") returned nil error, want non-nil")
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// Range is the inclusive date range [From, To] a Source is asked to fetch.
+// A zero From means "from the beginning of the station's record".
+type Range struct {
+	From time.Time
+	To   time.Time
+}
+
+// Source produces RawDatum values for one station, either by downloading
+// them from the upstream provider or by reading a local file when running
+// offline.
+type Source interface {
+	// Name is the station name used as the join key, e.g. "okhotsk".
+	Name() string
+	// Fetch returns the station's daily data for r, sorted by time.
+	Fetch(ctx context.Context, r Range) ([]RawDatum, error)
+}
+
+var sources = map[string]Source{}
+
+// Register adds s to the set of sources mainWithErr iterates over. Call it
+// once per station from registerSources; registering the same name twice
+// overwrites the previous entry.
+func Register(s Source) {
+	sources[s.Name()] = s
+}
+
+// Sources returns the registered sources sorted by name, so iteration order
+// is deterministic across runs.
+func Sources() []Source {
+	out := make([]Source, 0, len(sources))
+	for _, s := range sources {
+		out = append(out, s)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name() < out[j].Name() })
+	return out
+}
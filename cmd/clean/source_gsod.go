@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/text/encoding"
+)
+
+// gsodURLFmt downloads one station-year of NOAA Global Summary of the Day
+// data by its USAF-WBAN id. See
+// https://www.ncei.noaa.gov/access/search/data-search/global-summary-of-the-day
+const gsodURLFmt = "https://www.ncei.noaa.gov/data/global-summary-of-the-day/access/%d/%s.csv"
+
+// gsodEarliestYear bounds how far back Fetch paginates when r.From is zero.
+// GSOD's public archive starts in 1929, but Yelizovo's station has no
+// reports before the 1970s, so starting earlier would just waste requests
+// on years guaranteed to 404.
+const gsodEarliestYear = 1973
+
+// GSODSource downloads daily mean temperature for one GSOD station by its
+// USAF-WBAN id, falling back to a local copy when Offline is set.
+type GSODSource struct {
+	station   string
+	usafWBAN  string
+	LocalFile string
+	Cache     *httpCache
+	Offline   bool
+	// Encoding overrides character-set auto-detection. Leave nil to
+	// auto-detect; NOAA exports carry a UTF-8 BOM.
+	Encoding encoding.Encoding
+}
+
+func NewGSODSource(cache *httpCache, offline bool) *GSODSource {
+	return &GSODSource{station: "yelizovo", usafWBAN: "32061099999", LocalFile: "data/yelizovo.csv", Cache: cache, Offline: offline}
+}
+
+func (s *GSODSource) Name() string { return s.station }
+
+// Fetch downloads one GSOD file per year in [r.From, r.To], since NOAA
+// publishes a separate CSV per station-year. A zero r.From paginates back to
+// gsodEarliestYear.
+func (s *GSODSource) Fetch(ctx context.Context, r Range) ([]RawDatum, error) {
+	if s.Offline {
+		return readGSOD(s.LocalFile, s.Encoding)
+	}
+
+	fromYear := gsodEarliestYear
+	if !r.From.IsZero() {
+		fromYear = r.From.Year()
+	}
+
+	now := time.Now()
+	data := make([]RawDatum, 0)
+	for year := fromYear; year <= r.To.Year(); year++ {
+		// A year that has already ended never changes again, so it's safe
+		// to cache it under a fixed key. The current year is still being
+		// appended to by NOAA, so it must be cached under the actual fetch
+		// day or every tick after the first would serve a stale copy.
+		cacheDay := time.Date(year, time.December, 31, 0, 0, 0, 0, tzUTC)
+		if year == now.Year() {
+			cacheDay = r.To
+		}
+		url := fmt.Sprintf(gsodURLFmt, year, s.usafWBAN)
+		b, err := s.Cache.Get(ctx, url, cacheDay)
+		if err != nil {
+			return nil, errors.Wrap(err, url)
+		}
+		yearData, err := parseGSOD(bytes.NewReader(b), s.Encoding)
+		if err != nil {
+			return nil, errors.Wrap(err, url)
+		}
+		data = append(data, yearData...)
+	}
+	return data, nil
+}
+
+func readGSOD(fname string, enc encoding.Encoding) ([]RawDatum, error) {
+	f, err := os.Open(fname)
+	if err != nil {
+		return nil, errors.Wrap(err, "")
+	}
+	defer f.Close()
+	return parseGSOD(f, enc)
+}
+
+func parseGSOD(src io.Reader, enc encoding.Encoding) ([]RawDatum, error) {
+	r, err := newCSVReader(src, enc)
+	if err != nil {
+		return nil, errors.Wrap(err, "")
+	}
+
+	// Header.
+	if _, err := r.Read(); err != nil {
+		return nil, errors.Wrap(err, "")
+	}
+
+	data := make([]RawDatum, 0)
+	var i int = 1
+	for {
+		i++
+		row, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("%d", i))
+		}
+
+		t, err := time.ParseInLocation("2006-01-02", row[1], tzUTC)
+		if err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("%d", i))
+		}
+		v, err := strconv.ParseFloat(strings.TrimSpace(row[2]), 64)
+		if err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("%d", i))
+		}
+		v = (v - 32) * 5 / 9 // fahrenheit to celsius
+		d := RawDatum{t: t, v: v}
+
+		data = append(data, d)
+	}
+
+	return data, nil
+}
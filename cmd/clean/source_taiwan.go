@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/text/encoding"
+)
+
+// taiwanURLFmt is the Raingel historical_weather API, which serves one CSV
+// per station id. See https://github.com/Raingel/historical_weather
+const taiwanURLFmt = "https://e-service.cwa.gov.tw/HistoryDataQuery/MonthDataController.do?station=%s&stname=&datepicker="
+
+// TaiwanSource downloads daily data for a Raingel/CWA station, falling back
+// to a local copy when Offline is set.
+type TaiwanSource struct {
+	Station   string
+	LocalFile string
+	Cache     *httpCache
+	Offline   bool
+	// Encoding overrides character-set auto-detection. Leave nil to
+	// auto-detect.
+	Encoding encoding.Encoding
+}
+
+func NewTaiwanSource(cache *httpCache, offline bool) *TaiwanSource {
+	return &TaiwanSource{Station: "466900", LocalFile: "data/danshui.csv", Cache: cache, Offline: offline}
+}
+
+func (s *TaiwanSource) Name() string { return "danshui" }
+
+func (s *TaiwanSource) Fetch(ctx context.Context, r Range) ([]RawDatum, error) {
+	if s.Offline {
+		return readTaiwan(s.LocalFile, s.Encoding)
+	}
+	url := fmt.Sprintf(taiwanURLFmt, s.Station)
+	b, err := s.Cache.Get(ctx, url, r.To)
+	if err != nil {
+		return nil, errors.Wrap(err, "")
+	}
+	return parseTaiwan(bytes.NewReader(b), s.Encoding)
+}
+
+func readTaiwan(fpath string, enc encoding.Encoding) ([]RawDatum, error) {
+	f, err := os.Open(fpath)
+	if err != nil {
+		return nil, errors.Wrap(err, "")
+	}
+	defer f.Close()
+	return parseTaiwan(f, enc)
+}
+
+func parseTaiwan(src io.Reader, enc encoding.Encoding) ([]RawDatum, error) {
+	r, err := newCSVReader(src, enc)
+	if err != nil {
+		return nil, errors.Wrap(err, "")
+	}
+
+	// Header.
+	if _, err := r.Read(); err != nil {
+		return nil, errors.Wrap(err, "")
+	}
+
+	data := make([]RawDatum, 0)
+	var i int = 1
+	for {
+		i++
+		row, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("%d", i))
+		}
+
+		t, err := time.ParseInLocation("2006-01-02", row[0], tzTaipei)
+		if err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("%d", i))
+		}
+		v, err := strconv.ParseFloat(row[7], 64)
+		if err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("%d", i))
+		}
+
+		d := RawDatum{t: t, v: v}
+		data = append(data, d)
+	}
+
+	return data, nil
+}
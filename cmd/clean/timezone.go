@@ -0,0 +1,33 @@
+package main
+
+import (
+	"flag"
+	"time"
+)
+
+var referenceTZ = flag.String("reference-tz", "Asia/Tokyo", "IANA timezone used to decide which calendar day a station's reading belongs to when joining across stations")
+
+// Fixed zones for the stations whose source format reports dates in a
+// single, known timezone rather than UTC.
+var (
+	tzUTC    = time.UTC
+	tzJST    = mustLoadLocation("Asia/Tokyo")
+	tzTaipei = mustLoadLocation("Asia/Taipei")
+)
+
+func mustLoadLocation(name string) *time.Location {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		panic(err)
+	}
+	return loc
+}
+
+// joinDay returns the DateOnly-formatted calendar day t falls on once
+// converted to ref. This is the join key used to line up stations: two
+// RawDatum with different source timezones (e.g. Okhotsk's UTC vs.
+// Katsuura's JST) can report the "same day" only after both are viewed
+// through the same reference zone.
+func joinDay(t time.Time, ref *time.Location) string {
+	return t.In(ref).Format(time.DateOnly)
+}
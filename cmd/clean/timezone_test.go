@@ -0,0 +1,41 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestJoinDay(t *testing.T) {
+	tests := []struct {
+		name string
+		t    time.Time
+		ref  *time.Location
+		want string
+	}{
+		{
+			name: "same zone",
+			t:    time.Date(2024, time.March, 5, 10, 0, 0, 0, tzJST),
+			ref:  tzJST,
+			want: "2024-03-05",
+		},
+		{
+			name: "UTC evening rolls to the next JST day",
+			t:    time.Date(2024, time.March, 5, 20, 0, 0, 0, tzUTC),
+			ref:  tzJST,
+			want: "2024-03-06",
+		},
+		{
+			name: "Taipei to JST, one hour ahead, same day",
+			t:    time.Date(2024, time.March, 5, 23, 30, 0, 0, tzTaipei),
+			ref:  tzJST,
+			want: "2024-03-06",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := joinDay(tt.t, tt.ref); got != tt.want {
+				t.Errorf("joinDay(%v, %v) = %q, want %q", tt.t, tt.ref, got, tt.want)
+			}
+		})
+	}
+}